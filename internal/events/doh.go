@@ -0,0 +1,173 @@
+package events
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ma111e/melody/internal/config"
+	"github.com/ma111e/melody/internal/logdata"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// dnsMinMessageLength is the smallest a DNS wire-format message can legally be (a 12-byte header with no
+// question/answer sections). layers.DNS.DecodeFromBytes calls SetTruncated on its gopacket.DecodeFeedback for
+// anything shorter, so this is checked explicitly before decoding rather than relying on that callback
+const dnsMinMessageLength = 12
+
+// DoHQuestion mirrors a single question entry of a decoded DNS-over-HTTPS message
+type DoHQuestion struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+}
+
+// DoHAnswer mirrors a single resource record of a decoded DNS-over-HTTPS message
+type DoHAnswer struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+	TTL   uint32 `json:"ttl"`
+	Data  string `json:"data"`
+}
+
+// DoHEvent describes an HTTP(S) request identified as a DNS-over-HTTPS (RFC 8484) exchange. It carries both
+// the outer HTTP metadata and the decoded DNS message, so rules can match on either layer
+type DoHEvent struct {
+	HTTP      HTTPEvent     `json:"http"`
+	RCode     string        `json:"rcode"`
+	Questions []DoHQuestion `json:"questions"`
+	Answers   []DoHAnswer   `json:"answers"`
+	LogData   logdata.DoHEventLog
+	BaseEvent
+}
+
+// GetIPHeader satisfies the Event interface by returning nil, as DoHEvent is an application-level event
+func (ev DoHEvent) GetIPHeader() *layers.IPv4 {
+	return nil
+}
+
+// GetHTTPData returns the outer HTTP event carrying the DoH exchange
+func (ev DoHEvent) GetHTTPData() HTTPEvent {
+	return ev.HTTP
+}
+
+// ToLog parses the event structure and generates an EventLog ready to be sent to the logging file
+func (ev DoHEvent) ToLog() EventLog {
+	ev.LogData = logdata.DoHEventLog{}
+	ev.LogData.Timestamp = time.Now().Format(time.RFC3339Nano)
+	ev.LogData.Init(ev.BaseEvent)
+
+	ev.LogData.Session = ev.Session
+	ev.LogData.HTTP.Verb = ev.HTTP.Verb
+	ev.LogData.HTTP.RequestURI = ev.HTTP.RequestURI
+	ev.LogData.HTTP.DestHost = ev.HTTP.DestHost
+	ev.LogData.HTTP.IsTLS = ev.HTTP.IsTLS
+	ev.LogData.DestPort = ev.HTTP.DestPort
+	ev.LogData.SourceIP = ev.SourceIP
+
+	ev.LogData.DNS.RCode = ev.RCode
+	for _, q := range ev.Questions {
+		ev.LogData.DNS.Questions = append(ev.LogData.DNS.Questions, logdata.DoHQuestionLog(q))
+	}
+	for _, a := range ev.Answers {
+		ev.LogData.DNS.Answers = append(ev.LogData.DNS.Answers, logdata.DoHAnswerLog(a))
+	}
+
+	ev.LogData.Additional = ev.Additional
+
+	return ev.LogData
+}
+
+// IsDoHRequest reports whether r matches one of the two RFC 8484 wire-format transports: a POST carrying an
+// application/dns-message body, or a GET with the DNS message base64url-encoded in the "dns" query parameter
+func IsDoHRequest(r *http.Request) bool {
+	contentType := strings.ToLower(r.Header.Get("Content-Type"))
+
+	switch r.Method {
+	case http.MethodPost:
+		return strings.HasPrefix(contentType, "application/dns-message")
+	case http.MethodGet:
+		return r.URL.Query().Get("dns") != ""
+	default:
+		return false
+	}
+}
+
+// dohWireMessage extracts the raw DNS wire-format message carried by a DoH request. For a GET request it is
+// decoded from the base64url "dns" query parameter ; for a POST request, body is the payload already read by
+// httpparser.GetBodyPayload in NewHTTPEventFromRequest, passed through so the request body is only read once
+func dohWireMessage(r *http.Request, body []byte) ([]byte, error) {
+	if r.Method == http.MethodGet {
+		encoded := r.URL.Query().Get("dns")
+		return base64.RawURLEncoding.DecodeString(encoded)
+	}
+
+	return body, nil
+}
+
+// NewDoHEvent decodes the DNS message carried by a DoH request using gopacket/layers.DNS and pairs it with the
+// outer HTTPEvent built from the same request by NewHTTPEventFromRequest. body is the raw POST payload already
+// read by NewHTTPEventFromRequest ; it is ignored for GET requests.
+//
+// What Melody actually sees here is a DNS *query* sent by the client, not a response, so the Question section
+// is the part that's always populated and the part operators care about (which name is being resolved through
+// the tunnel). RCode and Answers are still decoded when present for completeness, but on real honeypot traffic
+// they'll be the query's own (meaningless) header bits and an empty answer section
+func NewDoHEvent(r *http.Request, httpEv *HTTPEvent, body []byte) (*DoHEvent, error) {
+	wire, err := dohWireMessage(r, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// layers.DNS.DecodeFromBytes calls df.SetTruncated() on anything shorter than a DNS header, and a nil
+	// gopacket.DecodeFeedback panics on that call ; a honeypot can be handed an empty or truncated body at
+	// will, so both the feedback and the length are guarded explicitly rather than trusting the library
+	if len(wire) < dnsMinMessageLength {
+		return nil, fmt.Errorf("DoH message is %d bytes, shorter than the %d byte DNS header", len(wire), dnsMinMessageLength)
+	}
+
+	var dns layers.DNS
+	if err := dns.DecodeFromBytes(wire, gopacket.NilDecodeFeedback); err != nil {
+		return nil, err
+	}
+
+	ev := &DoHEvent{
+		HTTP:  *httpEv,
+		RCode: dns.ResponseCode.String(),
+	}
+
+	for _, q := range dns.Questions {
+		ev.Questions = append(ev.Questions, DoHQuestion{
+			Name:  string(q.Name),
+			Type:  q.Type.String(),
+			Class: q.Class.String(),
+		})
+	}
+
+	// Answers are only meaningful when gopacket decoded this message as a response (QR bit set) ; a request
+	// carries an empty answer section by definition
+	if dns.QR {
+		for _, rr := range dns.Answers {
+			ev.Answers = append(ev.Answers, DoHAnswer{
+				Name:  string(rr.Name),
+				Type:  rr.Type.String(),
+				Class: rr.Class.String(),
+				TTL:   rr.TTL,
+				Data:  rr.String(),
+			})
+		}
+	}
+
+	ev.Session = httpEv.Session
+	ev.SourceIP = httpEv.SourceIP
+	ev.Tags = make(Tags)
+	ev.Additional = make(map[string]string)
+	ev.Kind = config.DoHKind
+
+	return ev, nil
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// encodeDNSQName turns "example.com" into its wire-format label encoding, terminated by the zero-length root
+// label, as used by buildDNSQuery
+func encodeDNSQName(name string) []byte {
+	var buf bytes.Buffer
+
+	for _, label := range strings.Split(name, ".") {
+		buf.WriteByte(byte(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0)
+
+	return buf.Bytes()
+}
+
+// buildDNSQuery builds a minimal, well-formed DNS wire-format query for a single A/IN question, as a DoH
+// client would send it
+func buildDNSQuery(qname string) []byte {
+	var buf bytes.Buffer
+
+	header := make([]byte, 12)
+	binary.BigEndian.PutUint16(header[0:2], 0x1234) // ID
+	binary.BigEndian.PutUint16(header[2:4], 0x0100) // flags : RD set, query
+	binary.BigEndian.PutUint16(header[4:6], 1)      // QDCOUNT
+	buf.Write(header)
+
+	buf.Write(encodeDNSQName(qname))
+	binary.Write(&buf, binary.BigEndian, uint16(1)) // QTYPE A
+	binary.Write(&buf, binary.BigEndian, uint16(1)) // QCLASS IN
+
+	return buf.Bytes()
+}
+
+func newDoHPostRequest(body []byte) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/dns-message")
+
+	return r
+}
+
+func TestNewDoHEventDecodesAQuery(t *testing.T) {
+	wire := buildDNSQuery("example.com")
+	r := newDoHPostRequest(wire)
+	httpEv := &HTTPEvent{}
+
+	ev, err := NewDoHEvent(r, httpEv, wire)
+	if err != nil {
+		t.Fatalf("NewDoHEvent() unexpected error: %v", err)
+	}
+
+	if len(ev.Questions) != 1 {
+		t.Fatalf("Questions = %v, want exactly one", ev.Questions)
+	}
+	if ev.Questions[0].Name != "example.com" {
+		t.Errorf("Questions[0].Name = %q, want %q", ev.Questions[0].Name, "example.com")
+	}
+	if len(ev.Answers) != 0 {
+		t.Errorf("Answers = %v, want none on a request", ev.Answers)
+	}
+}
+
+// TestNewDoHEventRejectsEmptyBody is a regression test : dns.DecodeFromBytes used to be called with a nil
+// gopacket.DecodeFeedback, which panics (via df.SetTruncated()) on anything shorter than a DNS header instead
+// of returning an error. An empty POST /dns-query body is fully attacker-controlled honeypot input.
+func TestNewDoHEventRejectsEmptyBody(t *testing.T) {
+	r := newDoHPostRequest(nil)
+	httpEv := &HTTPEvent{}
+
+	if _, err := NewDoHEvent(r, httpEv, nil); err == nil {
+		t.Fatal("NewDoHEvent() expected an error on an empty body, got nil")
+	}
+}
+
+// TestNewDoHEventRejectsSubHeaderLengthBody covers a body shorter than the 12-byte DNS header but not empty,
+// the other shape that used to reach dns.DecodeFromBytes with a nil feedback and panic
+func TestNewDoHEventRejectsSubHeaderLengthBody(t *testing.T) {
+	wire := []byte{0x12, 0x34, 0x01, 0x00, 0x00}
+	r := newDoHPostRequest(wire)
+	httpEv := &HTTPEvent{}
+
+	if _, err := NewDoHEvent(r, httpEv, wire); err == nil {
+		t.Fatal("NewDoHEvent() expected an error on a sub-header-length body, got nil")
+	}
+}
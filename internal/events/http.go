@@ -18,6 +18,7 @@ import (
 
 	"github.com/google/gopacket"
 	"github.com/ma111e/melody/internal/httpparser"
+	"github.com/ma111e/melody/internal/sniffer"
 )
 
 // HTTPEvent describes the structure of an event generated by a reassembled HTTP packet
@@ -32,9 +33,15 @@ type HTTPEvent struct {
 	HeadersKeys   []string          `json:"headers_keys"`
 	HeadersValues []string          `json:"headers_values"`
 	InlineHeaders []string
-	Errors        []string        `json:"errors"`
-	Body          logdata.Payload `json:"body"`
-	IsTLS         bool            `json:"is_tls"`
+	Errors        []string          `json:"errors"`
+	Body          logdata.Payload   `json:"body"`
+	ContentKind   string            `json:"content_kind"`
+	ParsedBody    interface{}       `json:"parsed_body,omitempty"`
+	IsTLS         bool              `json:"is_tls"`
+	IsWSUpgrade   bool              `json:"is_ws_upgrade"`
+	IsDoH         bool              `json:"is_doh"`
+	DoH           *DoHEvent         `json:"-"`
+	PathVars      map[string]string `json:"path_vars,omitempty"`
 	Req           *http.Request
 	LogData       logdata.HTTPEventLog
 	BaseEvent
@@ -79,6 +86,9 @@ func (ev HTTPEvent) ToLog() EventLog {
 	ev.LogData.SourceIP = ev.SourceIP
 	ev.LogData.HTTP.Headers = ev.Headers
 	ev.LogData.HTTP.Body = ev.Body
+	ev.LogData.HTTP.ContentKind = ev.ContentKind
+	ev.LogData.HTTP.ParsedBody = ev.ParsedBody
+	ev.LogData.HTTP.PathVars = ev.PathVars
 	ev.LogData.HTTP.IsTLS = ev.IsTLS
 	ev.LogData.Additional = ev.Additional
 
@@ -123,6 +133,8 @@ func NewHTTPEvent(r *http.Request, network gopacket.Flow, transport gopacket.Flo
 		errs = append(errs, err.Error())
 	}
 
+	sniffed := sniffer.Sniff(r.Header.Get("Content-Type"), params)
+
 	ev := &HTTPEvent{
 		Verb:          r.Method,
 		Proto:         r.Proto,
@@ -131,6 +143,8 @@ func NewHTTPEvent(r *http.Request, network gopacket.Flow, transport gopacket.Flo
 		DestPort:      uint16(dstPort),
 		DestHost:      network.Dst().String(),
 		Body:          logdata.NewPayloadLogData(params, config.Cfg.MaxPOSTDataSize),
+		ContentKind:   sniffed.Kind,
+		ParsedBody:    sniffed.Parsed,
 		IsTLS:         r.TLS != nil,
 		Headers:       headers,
 		InlineHeaders: inlineHeaders,
@@ -142,6 +156,8 @@ func NewHTTPEvent(r *http.Request, network gopacket.Flow, transport gopacket.Flo
 	ev.SourceIP = network.Src().String()
 	ev.Tags = make(Tags)
 	ev.Additional = make(map[string]string)
+	// Populated later by the rules engine when a path_template condition matches this event's RequestURI
+	ev.PathVars = make(map[string]string)
 
 	if ev.IsTLS {
 		ev.Kind = config.HTTPSKind
@@ -149,6 +165,13 @@ func NewHTTPEvent(r *http.Request, network gopacket.Flow, transport gopacket.Flo
 		ev.Kind = config.HTTPKind
 	}
 
+	ev.IsWSUpgrade = IsWebSocketUpgrade(r)
+	if ev.IsWSUpgrade {
+		ev.Additional["ws_key"] = r.Header.Get("Sec-WebSocket-Key")
+		ev.Additional["ws_requested_protocols"] = r.Header.Get("Sec-WebSocket-Protocol")
+		ev.Additional["ws_requested_extensions"] = r.Header.Get("Sec-WebSocket-Extensions")
+	}
+
 	return ev, nil
 }
 
@@ -188,6 +211,8 @@ func NewHTTPEventFromRequest(r *http.Request) (*HTTPEvent, error) {
 	srcPort, _ := strconv.ParseUint(rawSrcPort, 10, 16)
 	dstPort, _ := strconv.ParseUint(rawDstPort, 10, 16)
 
+	sniffed := sniffer.Sniff(r.Header.Get("Content-Type"), params)
+
 	ev := &HTTPEvent{
 		Verb:          r.Method,
 		Proto:         r.Proto,
@@ -196,6 +221,8 @@ func NewHTTPEventFromRequest(r *http.Request) (*HTTPEvent, error) {
 		DestPort:      uint16(dstPort),
 		DestHost:      dstHost,
 		Body:          logdata.NewPayloadLogData(params, config.Cfg.MaxPOSTDataSize),
+		ContentKind:   sniffed.Kind,
+		ParsedBody:    sniffed.Parsed,
 		IsTLS:         r.TLS != nil,
 		Headers:       headers,
 		InlineHeaders: inlineHeaders,
@@ -207,6 +234,8 @@ func NewHTTPEventFromRequest(r *http.Request) (*HTTPEvent, error) {
 	ev.SourceIP = srcIP
 	ev.Tags = make(Tags)
 	ev.Additional = make(map[string]string)
+	// Populated later by the rules engine when a path_template condition matches this event's RequestURI
+	ev.PathVars = make(map[string]string)
 
 	if ev.IsTLS {
 		ev.Kind = config.HTTPSKind
@@ -214,5 +243,27 @@ func NewHTTPEventFromRequest(r *http.Request) (*HTTPEvent, error) {
 		ev.Kind = config.HTTPKind
 	}
 
+	// The dummy webserver hijacks the connection and completes the handshake itself via UpgradeWebSocket
+	// once it sees this flag ; subsequent frames are logged as WSEvent under the same Session
+	ev.IsWSUpgrade = IsWebSocketUpgrade(r)
+	if ev.IsWSUpgrade {
+		ev.Additional["ws_key"] = r.Header.Get("Sec-WebSocket-Key")
+		ev.Additional["ws_requested_protocols"] = r.Header.Get("Sec-WebSocket-Protocol")
+		ev.Additional["ws_requested_extensions"] = r.Header.Get("Sec-WebSocket-Extensions")
+	}
+
+	// DoH requests (RFC 8484) are additionally decoded into a DoHEvent, reusing the body payload already read
+	// above. A decode failure (malformed wire message) is recorded in Errors rather than failing the whole
+	// HTTPEvent, since the outer HTTP request is still worth logging on its own
+	ev.IsDoH = IsDoHRequest(r)
+	if ev.IsDoH {
+		dohEv, err := NewDoHEvent(r, ev, params)
+		if err != nil {
+			ev.Errors = append(ev.Errors, err.Error())
+		} else {
+			ev.DoH = dohEv
+		}
+	}
+
 	return ev, nil
 }
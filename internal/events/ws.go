@@ -0,0 +1,295 @@
+package events
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ma111e/melody/internal/config"
+	"github.com/ma111e/melody/internal/logdata"
+
+	"github.com/google/gopacket/layers"
+)
+
+// websocketGUID is the magic value appended to the client's Sec-WebSocket-Key before hashing, as defined by
+// RFC 6455 section 1.3
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes, as defined by RFC 6455 section 11.8
+const (
+	WSOpcodeContinuation uint8 = 0x0
+	WSOpcodeText         uint8 = 0x1
+	WSOpcodeBinary       uint8 = 0x2
+	WSOpcodeClose        uint8 = 0x8
+	WSOpcodePing         uint8 = 0x9
+	WSOpcodePong         uint8 = 0xA
+)
+
+var wsOpcodeNames = map[uint8]string{
+	WSOpcodeContinuation: "continuation",
+	WSOpcodeText:         "text",
+	WSOpcodeBinary:       "binary",
+	WSOpcodeClose:        "close",
+	WSOpcodePing:         "ping",
+	WSOpcodePong:         "pong",
+}
+
+// WSDirection tells which side of the negotiated connection a captured frame travelled on
+type WSDirection string
+
+const (
+	WSDirectionClientToServer WSDirection = "c2s"
+	WSDirectionServerToClient WSDirection = "s2c"
+)
+
+// WSEvent describes a single WebSocket frame captured on a connection that was upgraded from an HTTP(S)
+// request seen by NewHTTPEventFromRequest. Every frame logged on a given connection shares the Session of
+// the HTTPEvent that carried the original handshake
+type WSEvent struct {
+	Opcode      uint8           `json:"opcode"`
+	OpcodeName  string          `json:"opcode_name"`
+	Direction   WSDirection     `json:"direction"`
+	Masked      bool            `json:"masked"`
+	PayloadLen  int             `json:"payload_len"`
+	Subprotocol string          `json:"subprotocol"`
+	Extensions  []string        `json:"extensions"`
+	Payload     logdata.Payload `json:"payload"`
+	LogData     logdata.WSEventLog
+	BaseEvent
+}
+
+// GetIPHeader satisfies the Event interface by returning nil. Like HTTPEvent, WSEvent is an application-level
+// event and does not carry IP header data
+func (ev WSEvent) GetIPHeader() *layers.IPv4 {
+	return nil
+}
+
+// ToLog parses the event structure and generates an EventLog ready to be sent to the logging file
+func (ev WSEvent) ToLog() EventLog {
+	ev.LogData = logdata.WSEventLog{}
+	ev.LogData.Timestamp = time.Now().Format(time.RFC3339Nano)
+	ev.LogData.Init(ev.BaseEvent)
+
+	ev.LogData.Session = ev.Session
+	ev.LogData.WS.Opcode = ev.Opcode
+	ev.LogData.WS.OpcodeName = ev.OpcodeName
+	ev.LogData.WS.Direction = string(ev.Direction)
+	ev.LogData.WS.Masked = ev.Masked
+	ev.LogData.WS.PayloadLen = ev.PayloadLen
+	ev.LogData.WS.Subprotocol = ev.Subprotocol
+	ev.LogData.WS.Extensions = ev.Extensions
+	ev.LogData.WS.Payload = ev.Payload
+	ev.LogData.Additional = ev.Additional
+
+	return ev.LogData
+}
+
+// IsWebSocketUpgrade reports whether r carries the headers of an RFC 6455 WebSocket upgrade request
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		tokenListContains(r.Header.Get("Connection"), "upgrade")
+}
+
+func tokenListContains(list, token string) bool {
+	for _, part := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wsHandshakeAccept computes the Sec-WebSocket-Accept value for the given client Sec-WebSocket-Key
+func wsHandshakeAccept(clientKey string) string {
+	h := sha1.New()
+	h.Write([]byte(clientKey + websocketGUID))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// UpgradeWebSocket performs the server side of the RFC 6455 handshake on the dummy webserver's connection for
+// an already-detected upgrade request, writes the 101 Switching Protocols response and returns a WSConn that
+// the caller can use to read and log subsequent frames under the HTTPEvent's Session. It is meant to be called
+// by the dummy webserver right after NewHTTPEventFromRequest set ev.IsWSUpgrade
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request, ev *HTTPEvent) (*WSConn, error) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("webserver connection does not support hijacking")
+	}
+
+	clientKey := r.Header.Get("Sec-WebSocket-Key")
+	if clientKey == "" {
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key header")
+	}
+
+	subprotocol := firstToken(r.Header.Get("Sec-WebSocket-Protocol"))
+	extensions := splitTokenList(r.Header.Get("Sec-WebSocket-Extensions"))
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	resp.WriteString("Upgrade: websocket\r\n")
+	resp.WriteString("Connection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + wsHandshakeAccept(clientKey) + "\r\n")
+	if subprotocol != "" {
+		resp.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	resp.WriteString("\r\n")
+
+	if _, err := rw.WriteString(resp.String()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ev.Additional["ws_subprotocol"] = subprotocol
+	if len(extensions) > 0 {
+		ev.Additional["ws_extensions"] = strings.Join(extensions, ", ")
+	}
+
+	return &WSConn{
+		conn:        conn,
+		reader:      rw.Reader,
+		session:     ev.Session,
+		subprotocol: subprotocol,
+		extensions:  extensions,
+	}, nil
+}
+
+// WSConn wraps a hijacked connection that has completed the WebSocket handshake so its frames can be read and
+// turned into WSEvent, tied to the originating HTTPEvent's Session
+type WSConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	session     string
+	subprotocol string
+	extensions  []string
+}
+
+// wsMaxFrameLength is an absolute ceiling on the declared length of a single frame, enforced before any
+// allocation happens. It protects against both the signed-overflow case below and an honest but huge length
+// on an unbounded connection ; maxPayload (derived from config.Cfg.MaxPOSTDataSize) further caps what is
+// actually captured, but the declared length must be sanity-checked first since it drives how much we discard
+const wsMaxFrameLength = 16 * 1024 * 1024 // 16 MiB
+
+// ReadFrame reads the next WebSocket frame off the connection, capping the captured payload at maxPayload
+// bytes, and turns it into a WSEvent tagged with the given direction. Melody faces attacker-controlled
+// traffic, so the declared payload length is decoded and bounds-checked as an unsigned value before anything
+// is allocated or read
+func (c *WSConn) ReadFrame(direction WSDirection, maxPayload int) (*WSEvent, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return nil, err
+	}
+
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	payloadLen := uint64(header[1] & 0x7F)
+
+	switch payloadLen {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, ext); err != nil {
+			return nil, err
+		}
+		payloadLen = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, ext); err != nil {
+			return nil, err
+		}
+		payloadLen = binary.BigEndian.Uint64(ext)
+	}
+
+	if payloadLen > wsMaxFrameLength {
+		return nil, fmt.Errorf("frame declares a payload length of %d bytes, exceeding the %d byte maximum", payloadLen, uint64(wsMaxFrameLength))
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, maskKey[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	toRead := payloadLen
+	if maxPayload > 0 && toRead > uint64(maxPayload) {
+		toRead = uint64(maxPayload)
+	}
+
+	payload := make([]byte, toRead)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+
+	if remaining := payloadLen - toRead; remaining > 0 {
+		if _, err := io.CopyN(io.Discard, c.reader, int64(remaining)); err != nil {
+			return nil, err
+		}
+	}
+
+	ev := &WSEvent{
+		Opcode:      opcode,
+		OpcodeName:  wsOpcodeNames[opcode],
+		Direction:   direction,
+		Masked:      masked,
+		PayloadLen:  int(payloadLen),
+		Subprotocol: c.subprotocol,
+		Extensions:  c.extensions,
+		Payload:     logdata.NewPayloadLogData(payload, maxPayload),
+	}
+
+	ev.Session = c.session
+	ev.Tags = make(Tags)
+	ev.Additional = make(map[string]string)
+	ev.Kind = config.WSKind
+
+	return ev, nil
+}
+
+// Close closes the underlying connection
+func (c *WSConn) Close() error {
+	return c.conn.Close()
+}
+
+func firstToken(list string) string {
+	for _, part := range strings.Split(list, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			return trimmed
+		}
+	}
+
+	return ""
+}
+
+func splitTokenList(list string) []string {
+	var tokens []string
+	for _, part := range strings.Split(list, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			tokens = append(tokens, trimmed)
+		}
+	}
+
+	return tokens
+}
@@ -0,0 +1,137 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// newTestWSConn builds a WSConn reading from the given raw frame bytes, bypassing the handshake since
+// ReadFrame only needs the buffered reader
+func newTestWSConn(frame []byte) *WSConn {
+	return &WSConn{reader: bufio.NewReader(bytes.NewReader(frame))}
+}
+
+func maskPayload(payload []byte, key [4]byte) []byte {
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ key[i%4]
+	}
+
+	return masked
+}
+
+func TestReadFrameSmallUnmaskedPayload(t *testing.T) {
+	payload := []byte("hello")
+	frame := []byte{0x81, byte(len(payload))}
+	frame = append(frame, payload...)
+
+	conn := newTestWSConn(frame)
+
+	ev, err := conn.ReadFrame(WSDirectionServerToClient, 0)
+	if err != nil {
+		t.Fatalf("ReadFrame() unexpected error: %v", err)
+	}
+
+	if ev.Opcode != WSOpcodeText {
+		t.Errorf("Opcode = %#x, want %#x", ev.Opcode, WSOpcodeText)
+	}
+	if ev.Masked {
+		t.Errorf("Masked = true, want false")
+	}
+	if ev.PayloadLen != len(payload) {
+		t.Errorf("PayloadLen = %d, want %d", ev.PayloadLen, len(payload))
+	}
+}
+
+func TestReadFrameMaskedPayload(t *testing.T) {
+	payload := []byte("attacker-controlled")
+	key := [4]byte{0xDE, 0xAD, 0xBE, 0xEF}
+
+	frame := []byte{0x82, 0x80 | byte(len(payload))}
+	frame = append(frame, key[:]...)
+	frame = append(frame, maskPayload(payload, key)...)
+
+	conn := newTestWSConn(frame)
+
+	ev, err := conn.ReadFrame(WSDirectionClientToServer, 0)
+	if err != nil {
+		t.Fatalf("ReadFrame() unexpected error: %v", err)
+	}
+
+	if !ev.Masked {
+		t.Errorf("Masked = false, want true")
+	}
+	if ev.PayloadLen != len(payload) {
+		t.Errorf("PayloadLen = %d, want %d", ev.PayloadLen, len(payload))
+	}
+}
+
+func TestReadFrameExtended16BitLength(t *testing.T) {
+	payload := bytes.Repeat([]byte{'A'}, 300)
+
+	frame := []byte{0x82, 126}
+	ext := make([]byte, 2)
+	binary.BigEndian.PutUint16(ext, uint16(len(payload)))
+	frame = append(frame, ext...)
+	frame = append(frame, payload...)
+
+	conn := newTestWSConn(frame)
+
+	ev, err := conn.ReadFrame(WSDirectionServerToClient, 0)
+	if err != nil {
+		t.Fatalf("ReadFrame() unexpected error: %v", err)
+	}
+
+	if ev.PayloadLen != len(payload) {
+		t.Errorf("PayloadLen = %d, want %d", ev.PayloadLen, len(payload))
+	}
+}
+
+func TestReadFrameRespectsMaxPayloadCap(t *testing.T) {
+	payload := bytes.Repeat([]byte{'B'}, 100)
+	const maxPayload = 10
+
+	frame := []byte{0x82, byte(len(payload))}
+	frame = append(frame, payload...)
+
+	conn := newTestWSConn(frame)
+
+	ev, err := conn.ReadFrame(WSDirectionServerToClient, maxPayload)
+	if err != nil {
+		t.Fatalf("ReadFrame() unexpected error: %v", err)
+	}
+
+	if ev.PayloadLen != len(payload) {
+		t.Errorf("PayloadLen = %d, want the full declared length %d", ev.PayloadLen, len(payload))
+	}
+	if len(ev.Payload.Raw) > maxPayload {
+		t.Errorf("captured payload length = %d, want at most %d", len(ev.Payload.Raw), maxPayload)
+	}
+}
+
+// TestReadFrameRejectsOversizedExtendedLength is a regression test for a signed-integer overflow : a 64-bit
+// extended length with the high bit set used to be decoded into a signed int, wrapping negative and bypassing
+// the maxPayload cap entirely before make([]byte, toRead) panicked. ReadFrame must instead reject any declared
+// length over wsMaxFrameLength before allocating anything
+func TestReadFrameRejectsOversizedExtendedLength(t *testing.T) {
+	frame := []byte{0x82, 127}
+	ext := make([]byte, 8)
+	binary.BigEndian.PutUint64(ext, 1<<63)
+	frame = append(frame, ext...)
+
+	conn := newTestWSConn(frame)
+
+	if _, err := conn.ReadFrame(WSDirectionServerToClient, 0); err == nil {
+		t.Fatal("ReadFrame() expected an error for an oversized declared length, got nil")
+	}
+}
+
+func TestReadFrameTruncatedHeaderReturnsError(t *testing.T) {
+	conn := newTestWSConn([]byte{0x81})
+
+	if _, err := conn.ReadFrame(WSDirectionServerToClient, 0); err == nil {
+		t.Fatal("ReadFrame() expected an error on a truncated header, got nil")
+	}
+}
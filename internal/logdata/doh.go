@@ -0,0 +1,38 @@
+package logdata
+
+// DoHQuestionLog mirrors a question entry of a decoded DNS-over-HTTPS message in the on-disk log format
+type DoHQuestionLog struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+}
+
+// DoHAnswerLog mirrors a resource record of a decoded DNS-over-HTTPS message in the on-disk log format
+type DoHAnswerLog struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+	TTL   uint32 `json:"ttl"`
+	Data  string `json:"data"`
+}
+
+// DoHEventLog is the on-disk representation of a DNS-over-HTTPS exchange, carrying both the outer HTTP
+// metadata and the decoded DNS message
+type DoHEventLog struct {
+	Timestamp string `json:"timestamp"`
+	Session   string `json:"session"`
+	SourceIP  string `json:"src_ip"`
+	DestPort  uint16 `json:"dst_port"`
+	HTTP      struct {
+		Verb       string `json:"verb"`
+		RequestURI string `json:"URI"`
+		DestHost   string `json:"dst_host"`
+		IsTLS      bool   `json:"is_tls"`
+	} `json:"http"`
+	DNS struct {
+		RCode     string           `json:"rcode"`
+		Questions []DoHQuestionLog `json:"questions"`
+		Answers   []DoHAnswerLog   `json:"answers"`
+	} `json:"dns"`
+	Additional map[string]string `json:"additional"`
+}
@@ -0,0 +1,20 @@
+package logdata
+
+// WSEventLog is the on-disk representation of a captured WebSocket frame, paralleling HTTPEventLog
+type WSEventLog struct {
+	Timestamp string `json:"timestamp"`
+	Session   string `json:"session"`
+	SourceIP  string `json:"src_ip"`
+	DestPort  uint16 `json:"dst_port"`
+	WS        struct {
+		Opcode      uint8    `json:"opcode"`
+		OpcodeName  string   `json:"opcode_name"`
+		Direction   string   `json:"direction"`
+		Masked      bool     `json:"masked"`
+		PayloadLen  int      `json:"payload_len"`
+		Subprotocol string   `json:"subprotocol"`
+		Extensions  []string `json:"extensions"`
+		Payload     Payload  `json:"payload"`
+	} `json:"ws"`
+	Additional map[string]string `json:"additional"`
+}
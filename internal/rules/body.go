@@ -0,0 +1,135 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bonjourmalware/melody/internal/events"
+)
+
+// BodyConditions describes the optional conditions of an HTTP Rule matching against the structured payload
+// produced by the sniffer package, complementing the existing raw-byte body regex
+type BodyConditions struct {
+	Kind     string `yaml:"kind"`
+	Path     string `yaml:"path"`
+	Value    string `yaml:"value"`
+	valueRgx *regexp.Regexp
+}
+
+// Compile builds the Value matcher once at load time ; Match runs on the per-request hot path and
+// regexp.Compile is too expensive to repeat there
+func (c *BodyConditions) Compile() error {
+	if c.Value == "" {
+		return nil
+	}
+
+	rgx, err := regexp.Compile(c.Value)
+	if err != nil {
+		return err
+	}
+
+	c.valueRgx = rgx
+
+	return nil
+}
+
+// Match reports whether the given HTTP event's sniffed body satisfies the body conditions : its Kind matches
+// body.kind, and, when Path is set, the JSONPath-style selector resolves to a value matching Value
+func (c BodyConditions) Match(ev events.HTTPEvent) bool {
+	if c.Kind != "" && c.Kind != ev.ContentKind {
+		return false
+	}
+
+	if c.Path == "" {
+		return true
+	}
+
+	value, ok := resolveBodyPath(ev.ParsedBody, c.Path)
+	if !ok {
+		return false
+	}
+
+	if c.valueRgx == nil {
+		return true
+	}
+
+	return c.valueRgx.MatchString(fmt.Sprintf("%v", value))
+}
+
+// resolveBodyPath walks a JSONPath-style dot/bracket selector (e.g. "user.roles[0].name") against a decoded
+// body.parsed value. It only supports the subset needed to navigate maps and slices produced by the sniffer
+// package ; an unresolvable path returns ok=false
+func resolveBodyPath(parsed interface{}, path string) (interface{}, bool) {
+	current := parsed
+
+	tokens, ok := splitBodyPath(path)
+	if !ok {
+		return nil, false
+	}
+
+	for _, token := range tokens {
+		if token.index != nil {
+			arr, ok := current.([]interface{})
+			if !ok || *token.index >= len(arr) || *token.index < 0 {
+				return nil, false
+			}
+
+			current = arr[*token.index]
+			continue
+		}
+
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = obj[token.key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+type bodyPathToken struct {
+	key   string
+	index *int
+}
+
+// splitBodyPath turns "user.roles[0].name" into [{key:"user"} {key:"roles"} {index:0} {key:"name"}]. It
+// returns ok=false on a malformed index (an unclosed bracket or a non-integer index)
+func splitBodyPath(path string) ([]bodyPathToken, bool) {
+	var tokens []bodyPathToken
+
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		for len(segment) > 0 {
+			if bracket := strings.IndexByte(segment, '['); bracket == 0 {
+				end := strings.IndexByte(segment, ']')
+				if end < 0 {
+					return nil, false
+				}
+
+				idx, err := strconv.Atoi(segment[1:end])
+				if err != nil {
+					return nil, false
+				}
+
+				tokens = append(tokens, bodyPathToken{index: &idx})
+				segment = segment[end+1:]
+				continue
+			} else if bracket > 0 {
+				tokens = append(tokens, bodyPathToken{key: segment[:bracket]})
+				segment = segment[bracket:]
+				continue
+			}
+
+			tokens = append(tokens, bodyPathToken{key: segment})
+			segment = ""
+		}
+	}
+
+	return tokens, true
+}
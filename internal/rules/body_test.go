@@ -0,0 +1,77 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/bonjourmalware/melody/internal/events"
+)
+
+func TestResolveBodyPath(t *testing.T) {
+	parsed := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "alice",
+			"roles": []interface{}{
+				"admin",
+				map[string]interface{}{"name": "editor"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		wantValue interface{}
+		wantOk    bool
+	}{
+		{name: "simple key", path: "user.name", wantValue: "alice", wantOk: true},
+		{name: "array index", path: "user.roles[0]", wantValue: "admin", wantOk: true},
+		{name: "nested object inside array", path: "user.roles[1].name", wantValue: "editor", wantOk: true},
+		{name: "leading dollar-dot prefix", path: "$.user.name", wantValue: "alice", wantOk: true},
+		{name: "unknown key", path: "user.age", wantOk: false},
+		{name: "index out of range", path: "user.roles[5]", wantOk: false},
+		{name: "negative index", path: "user.roles[-1]", wantOk: false},
+		{name: "index into a non-array", path: "user.name[0]", wantOk: false},
+		{name: "key into a non-object", path: "user.name.first", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok := resolveBodyPath(parsed, tt.path)
+			if ok != tt.wantOk {
+				t.Fatalf("resolveBodyPath(%q) ok = %v, want %v", tt.path, ok, tt.wantOk)
+			}
+
+			if tt.wantOk && value != tt.wantValue {
+				t.Fatalf("resolveBodyPath(%q) value = %v, want %v", tt.path, value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestBodyConditionsMatch(t *testing.T) {
+	conditions := BodyConditions{
+		Kind:  "json",
+		Path:  "user.name",
+		Value: "^ali",
+	}
+
+	if err := conditions.Compile(); err != nil {
+		t.Fatalf("Compile() unexpected error: %v", err)
+	}
+
+	ev := events.HTTPEvent{
+		ContentKind: "json",
+		ParsedBody: map[string]interface{}{
+			"user": map[string]interface{}{"name": "alice"},
+		},
+	}
+
+	if !conditions.Match(ev) {
+		t.Fatal("Match() = false, want true for a matching kind/path/value")
+	}
+
+	ev.ContentKind = "xml"
+	if conditions.Match(ev) {
+		t.Fatal("Match() = true, want false when Kind doesn't match ContentKind")
+	}
+}
@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"regexp"
+
+	"github.com/bonjourmalware/melody/internal/events"
+)
+
+// DoHConditions describes the optional conditions of a Rule whose Layer is "doh". They complement the common
+// conditions (host, tags, ...) shared by every layer and are evaluated against the DNS message decoded from a
+// DoH exchange rather than against raw HTTP fields
+type DoHConditions struct {
+	QName    string `yaml:"qname"`
+	QType    string `yaml:"qtype"`
+	RCode    string `yaml:"rcode"`
+	qnameRgx *regexp.Regexp
+}
+
+// Compile turns QName into a regexp.Regexp ahead of time ; DoH exchanges can arrive fast enough that
+// compiling it again for every message would show up in profiles
+func (c *DoHConditions) Compile() error {
+	if c.QName == "" {
+		return nil
+	}
+
+	rgx, err := regexp.Compile(c.QName)
+	if err != nil {
+		return err
+	}
+
+	c.qnameRgx = rgx
+
+	return nil
+}
+
+// Match reports whether the given DoH event satisfies the DoH-specific conditions
+func (c DoHConditions) Match(ev events.DoHEvent) bool {
+	if c.RCode != "" && c.RCode != ev.RCode {
+		return false
+	}
+
+	if c.QType != "" {
+		var found bool
+		for _, q := range ev.Questions {
+			if q.Type == c.QType {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if c.qnameRgx != nil {
+		var found bool
+		for _, q := range ev.Questions {
+			if c.qnameRgx.MatchString(q.Name) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
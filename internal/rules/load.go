@@ -9,20 +9,35 @@ import (
 	"strings"
 
 	"github.com/bonjourmalware/melody/internal/config"
+	"github.com/bonjourmalware/melody/internal/rules/pathtemplate"
 
 	"gopkg.in/yaml.v3"
 )
 
-var (
-	// GlobalRules is the global object holding all the loaded rules
-	GlobalRules = make(map[string][]Rules)
-)
-
 // GlobalRawRules describes a set of RawRules
 type GlobalRawRules []RawRules
 
-// LoadRulesDir walks the given directory to find rule files and load them into GlobalRules
+// LoadRulesDir walks the given directory to find rule files, parses them and swaps them into Store. It is
+// meant to be called once at startup ; a failure to parse the directory is fatal, since there is no previous
+// ruleset to fall back on. Use WatchRulesDir to keep Store up to date afterwards without restarting Melody
 func LoadRulesDir(rulesDir string) uint {
+	ruleset, _, total, err := parseRulesDir(rulesDir)
+	if err != nil {
+		log.Println(fmt.Sprintf("Failed to load rule directory [%s]", rulesDir))
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	Store.Swap(ruleset)
+
+	return total
+}
+
+// parseRulesDir walks rulesDir, parses every .yml file it finds into a candidate ruleset keyed by protocol/
+// layer and returns it without touching Store, so both the startup loader and the hot-reload watcher can
+// validate a candidate ruleset before deciding whether to swap it in. It also returns the RawRule each Rule
+// was compiled from, keyed by name, so a reload can diff rule *sources* instead of their compiled form
+func parseRulesDir(rulesDir string) (map[string][]Rules, map[string]RawRule, uint, error) {
 	var globalRawRules GlobalRawRules
 	var total uint
 
@@ -45,52 +60,51 @@ func LoadRulesDir(rulesDir string) uint {
 				}
 			}
 
-			log.Println("Parsing", path)
-			if strings.HasSuffix(path, ".yml") {
-				parsed, err := ParseYAMLRulesFile(path)
-				if err != nil {
-					log.Println(fmt.Sprintf("Failed to read YAML rule file [%s]", path))
-					log.Println(err)
-					os.Exit(1)
-				}
-
-				globalRawRules = append(globalRawRules, parsed)
-			} else {
+			if !strings.HasSuffix(path, ".yml") {
 				log.Println("invalid rule file (wanted : .yml) :", path)
+				return nil
 			}
 
+			log.Println("Parsing", path)
+			parsed, err := ParseYAMLRulesFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read YAML rule file [%s]: %w", path, err)
+			}
+
+			globalRawRules = append(globalRawRules, parsed)
+
 			return nil
 		})
 
 	if err != nil {
-		log.Println(fmt.Sprintf("Failed to parse rule directory [%s]", rulesDir))
-		log.Println(err)
-		os.Exit(1)
+		return nil, nil, 0, fmt.Errorf("failed to parse rule directory [%s]: %w", rulesDir, err)
 	}
 
+	ruleset := make(map[string][]Rules)
+	rawByName := make(map[string]RawRule)
+
 	for _, rawRules := range globalRawRules {
-		rules := Rules{}
+		parsedRules := Rules{}
 		for ruleName, rawRule := range rawRules {
 			rule := rawRule.Parse()
 			rule.Name = ruleName
 
-			rules = append(rules, rule)
+			parsedRules = append(parsedRules, rule)
+			rawByName[ruleName] = rawRule
 		}
 
 		for _, proto := range config.Cfg.MatchProtocols {
-			GlobalRules[proto] = append(GlobalRules[proto], rules.Filter(func(rule Rule) bool { return rule.Layer == proto }))
+			ruleset[proto] = append(ruleset[proto], parsedRules.Filter(func(rule Rule) bool { return rule.Layer == proto }))
 		}
-
-		//GlobalRules = append(GlobalRules, rules)
 	}
 
-	for _, protocolRules := range GlobalRules {
-		for _, ruleset := range protocolRules {
-			total += uint(len(ruleset))
+	for _, protocolRules := range ruleset {
+		for _, rules := range protocolRules {
+			total += uint(len(rules))
 		}
 	}
 
-	return total
+	return ruleset, rawByName, total, nil
 }
 
 // ParseYAMLRulesFile is an helper that parses the given YAML file and return a set of raw rules as RawRules
@@ -105,5 +119,28 @@ func ParseYAMLRulesFile(filepath string) (RawRules, error) {
 		return RawRules{}, err
 	}
 
+	if err := validatePathTemplates(rawRules); err != nil {
+		return RawRules{}, err
+	}
+
 	return rawRules, nil
 }
+
+// validatePathTemplates compiles the path_template of every already-parsed RawRule, failing with a clear error
+// on the first malformed one. It validates off the same RawRules ParseYAMLRulesFile is about to return, rather
+// than re-decoding the YAML into a parallel shape, so it can never disagree with how the file actually parsed.
+// The compiled Template is discarded here ; RawRule.Parse compiles its own copy when it builds the Rule, this
+// pass only exists to reject bad rule files at load time instead of at first match
+func validatePathTemplates(rawRules RawRules) error {
+	for name, rawRule := range rawRules {
+		if rawRule.PathTemplate == "" {
+			continue
+		}
+
+		if _, err := pathtemplate.Compile(rawRule.PathTemplate); err != nil {
+			return fmt.Errorf("rule %q: invalid path_template: %w", name, err)
+		}
+	}
+
+	return nil
+}
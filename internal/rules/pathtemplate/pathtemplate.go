@@ -0,0 +1,127 @@
+// Package pathtemplate implements a small grpc-gateway-style route matcher for HTTP rules, supporting
+// literal segments, single-segment variables ({id}) and a trailing catch-all variable ({rest=**})
+package pathtemplate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type nodeKind int
+
+const (
+	literalNode nodeKind = iota
+	singleNode
+	catchAllNode
+)
+
+type segment struct {
+	kind    nodeKind
+	literal string
+	varName string
+}
+
+// Template is a compiled path template, ready to be matched against request URIs
+type Template struct {
+	raw      string
+	segments []segment
+}
+
+var varNameRgx = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Compile parses a pattern such as "/api/v1/users/{id}/posts/{postID=**}" into a Template. It fails with a
+// descriptive error if the pattern is empty, contains an unterminated variable, an invalid variable name, or
+// a catch-all variable ({var=**}) that is not the last segment
+func Compile(pattern string) (*Template, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, fmt.Errorf("empty path template")
+	}
+
+	rawSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	segments := make([]segment, 0, len(rawSegments))
+
+	for i, raw := range rawSegments {
+		if raw == "" {
+			return nil, fmt.Errorf("empty segment in path template %q", pattern)
+		}
+
+		if !strings.HasPrefix(raw, "{") || !strings.HasSuffix(raw, "}") {
+			if strings.ContainsAny(raw, "{}") {
+				return nil, fmt.Errorf("unterminated variable %q in path template %q", raw, pattern)
+			}
+
+			segments = append(segments, segment{kind: literalNode, literal: raw})
+			continue
+		}
+
+		inner := raw[1 : len(raw)-1]
+
+		if strings.HasSuffix(inner, "=**") {
+			if i != len(rawSegments)-1 {
+				return nil, fmt.Errorf("catch-all variable %q must be the last segment of %q", raw, pattern)
+			}
+
+			varName := strings.TrimSuffix(inner, "=**")
+			if !varNameRgx.MatchString(varName) {
+				return nil, fmt.Errorf("invalid catch-all variable name %q in %q", varName, pattern)
+			}
+
+			segments = append(segments, segment{kind: catchAllNode, varName: varName})
+			continue
+		}
+
+		if !varNameRgx.MatchString(inner) {
+			return nil, fmt.Errorf("invalid variable name %q in %q", inner, pattern)
+		}
+
+		segments = append(segments, segment{kind: singleNode, varName: inner})
+	}
+
+	return &Template{raw: pattern, segments: segments}, nil
+}
+
+// String returns the original, uncompiled pattern
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Match reports whether uri matches the template and, if so, returns the variables captured from its
+// single-segment and catch-all nodes
+func (t *Template) Match(uri string) (map[string]string, bool) {
+	path := strings.SplitN(strings.TrimPrefix(uri, "/"), "?", 2)[0]
+	path = strings.Trim(path, "/")
+
+	var pathSegments []string
+	if path != "" {
+		pathSegments = strings.Split(path, "/")
+	}
+
+	vars := make(map[string]string)
+
+	for i, seg := range t.segments {
+		if seg.kind == catchAllNode {
+			vars[seg.varName] = strings.Join(pathSegments[i:], "/")
+			return vars, true
+		}
+
+		if i >= len(pathSegments) {
+			return nil, false
+		}
+
+		switch seg.kind {
+		case literalNode:
+			if pathSegments[i] != seg.literal {
+				return nil, false
+			}
+		case singleNode:
+			vars[seg.varName] = pathSegments[i]
+		}
+	}
+
+	if len(pathSegments) != len(t.segments) {
+		return nil, false
+	}
+
+	return vars, true
+}
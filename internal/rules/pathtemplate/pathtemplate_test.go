@@ -0,0 +1,118 @@
+package pathtemplate
+
+import "testing"
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr bool
+	}{
+		{name: "literal", pattern: "/api/v1/users", wantErr: false},
+		{name: "single variable", pattern: "/api/v1/users/{id}", wantErr: false},
+		{name: "trailing catch-all", pattern: "/api/v1/users/{id}/posts/{rest=**}", wantErr: false},
+		{name: "empty pattern", pattern: "", wantErr: true},
+		{name: "blank pattern", pattern: "   ", wantErr: true},
+		{name: "empty segment", pattern: "/api//users", wantErr: true},
+		{name: "unterminated variable", pattern: "/api/{id", wantErr: true},
+		{name: "invalid variable name", pattern: "/api/{1id}", wantErr: true},
+		{name: "catch-all not last segment", pattern: "/api/{rest=**}/users", wantErr: true},
+		{name: "invalid catch-all variable name", pattern: "/api/{1rest=**}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compile(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		uri      string
+		wantVars map[string]string
+		wantOk   bool
+	}{
+		{
+			name:     "literal match",
+			pattern:  "/api/v1/users",
+			uri:      "/api/v1/users",
+			wantVars: map[string]string{},
+			wantOk:   true,
+		},
+		{
+			name:    "literal mismatch",
+			pattern: "/api/v1/users",
+			uri:     "/api/v1/posts",
+			wantOk:  false,
+		},
+		{
+			name:     "single variable capture",
+			pattern:  "/api/v1/users/{id}",
+			uri:      "/api/v1/users/42",
+			wantVars: map[string]string{"id": "42"},
+			wantOk:   true,
+		},
+		{
+			name:    "too few segments",
+			pattern: "/api/v1/users/{id}",
+			uri:     "/api/v1/users",
+			wantOk:  false,
+		},
+		{
+			name:    "too many segments",
+			pattern: "/api/v1/users/{id}",
+			uri:     "/api/v1/users/42/extra",
+			wantOk:  false,
+		},
+		{
+			name:     "catch-all captures the remainder",
+			pattern:  "/api/v1/users/{id}/posts/{rest=**}",
+			uri:      "/api/v1/users/42/posts/2024/07/slug",
+			wantVars: map[string]string{"id": "42", "rest": "2024/07/slug"},
+			wantOk:   true,
+		},
+		{
+			name:    "query string is ignored when matching",
+			pattern: "/api/v1/users/{id}",
+			uri:     "/api/v1/users/42?verbose=true",
+			wantVars: map[string]string{
+				"id": "42",
+			},
+			wantOk: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := Compile(tt.pattern)
+			if err != nil {
+				t.Fatalf("Compile(%q) unexpected error: %v", tt.pattern, err)
+			}
+
+			vars, ok := tpl.Match(tt.uri)
+			if ok != tt.wantOk {
+				t.Fatalf("Match(%q) ok = %v, want %v", tt.uri, ok, tt.wantOk)
+			}
+
+			if !tt.wantOk {
+				return
+			}
+
+			if len(vars) != len(tt.wantVars) {
+				t.Fatalf("Match(%q) vars = %v, want %v", tt.uri, vars, tt.wantVars)
+			}
+
+			for k, v := range tt.wantVars {
+				if vars[k] != v {
+					t.Fatalf("Match(%q) vars[%q] = %q, want %q", tt.uri, k, vars[k], v)
+				}
+			}
+		})
+	}
+}
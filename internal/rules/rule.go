@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"github.com/bonjourmalware/melody/internal/events"
+	"github.com/bonjourmalware/melody/internal/rules/pathtemplate"
+)
+
+// RawRule is the direct YAML decoding of a single rule entry, before any of its regexes or its path_template
+// are compiled
+type RawRule struct {
+	Layer        string          `yaml:"layer"`
+	PathTemplate string          `yaml:"path_template"`
+	WS           *WSConditions   `yaml:"ws"`
+	DoH          *DoHConditions  `yaml:"doh"`
+	Body         *BodyConditions `yaml:"body"`
+}
+
+// RawRules maps rule names to their raw, not-yet-compiled definition, exactly as they appear in a YAML rule file
+type RawRules map[string]RawRule
+
+// Rule is a RawRule that has been compiled : its path_template, and the regexes of its ws/doh/body conditions,
+// are parsed once here so the hot match path never recompiles them per event
+type Rule struct {
+	Name         string
+	Layer        string
+	PathTemplate *pathtemplate.Template
+	WS           *WSConditions
+	DoH          *DoHConditions
+	Body         *BodyConditions
+}
+
+// Rules is a set of compiled Rule
+type Rules []Rule
+
+// Filter returns the subset of Rules for which fn returns true
+func (rs Rules) Filter(fn func(Rule) bool) Rules {
+	var out Rules
+
+	for _, rule := range rs {
+		if fn(rule) {
+			out = append(out, rule)
+		}
+	}
+
+	return out
+}
+
+// Parse compiles a RawRule into a Rule. A malformed path_template was already rejected by ParseYAMLRulesFile at
+// load time, so a path_template error here is only possible if that validation was skipped, and the template
+// is then left unset. The ws/doh/body condition regexes are not validated at load time : one that fails to
+// compile is left unset, and that condition then places no restriction on the match
+func (r RawRule) Parse() Rule {
+	rule := Rule{
+		Layer: r.Layer,
+	}
+
+	// WS/DoH/Body are cloned rather than reused as-is : Compile mutates the struct in place (it fills in the
+	// unexported compiled regex fields), and r.WS/r.DoH/r.Body are the same pointers callers keep around in a
+	// RawRule for diffing rule sources across a reload, which must stay untouched by compilation
+	if r.WS != nil {
+		ws := *r.WS
+		_ = ws.Compile()
+		rule.WS = &ws
+	}
+
+	if r.DoH != nil {
+		doh := *r.DoH
+		_ = doh.Compile()
+		rule.DoH = &doh
+	}
+
+	if r.Body != nil {
+		body := *r.Body
+		_ = body.Compile()
+		rule.Body = &body
+	}
+
+	if r.PathTemplate != "" {
+		if tpl, err := pathtemplate.Compile(r.PathTemplate); err == nil {
+			rule.PathTemplate = tpl
+		}
+	}
+
+	return rule
+}
+
+// MatchHTTP reports whether an HTTP-layer rule matches ev, evaluating its path_template and body conditions
+// when set. A matching path_template binds its captured variables into ev.PathVars so later conditions on the
+// same rule, and any logging done afterwards, can reference them
+func (r Rule) MatchHTTP(ev *events.HTTPEvent) bool {
+	if r.PathTemplate != nil {
+		vars, ok := r.PathTemplate.Match(ev.RequestURI)
+		if !ok {
+			return false
+		}
+
+		for name, value := range vars {
+			ev.PathVars[name] = value
+		}
+	}
+
+	if r.Body != nil && !r.Body.Match(*ev) {
+		return false
+	}
+
+	return true
+}
+
+// MatchWS reports whether a ws-layer rule matches the given WebSocket frame
+func (r Rule) MatchWS(ev events.WSEvent) bool {
+	if r.WS == nil {
+		return true
+	}
+
+	return r.WS.Match(ev)
+}
+
+// MatchDoH reports whether a doh-layer rule matches the given DoH exchange
+func (r Rule) MatchDoH(ev events.DoHEvent) bool {
+	if r.DoH == nil {
+		return true
+	}
+
+	return r.DoH.Match(ev)
+}
@@ -0,0 +1,49 @@
+package rules
+
+import (
+	"sync/atomic"
+)
+
+// RuleStore holds the currently active ruleset, keyed by protocol/layer, behind an atomic.Value so the
+// packet-processing hot path can read it without locking while WatchRulesDir swaps in a freshly parsed
+// ruleset in the background
+type RuleStore struct {
+	value atomic.Value
+}
+
+// NewRuleStore returns a RuleStore initialised with an empty ruleset
+func NewRuleStore() *RuleStore {
+	s := &RuleStore{}
+	s.value.Store(make(map[string][]Rules))
+
+	return s
+}
+
+// Get returns the currently active rulesets for the given protocol/layer
+func (s *RuleStore) Get(proto string) []Rules {
+	return s.value.Load().(map[string][]Rules)[proto]
+}
+
+// All returns the currently active ruleset for every protocol/layer
+func (s *RuleStore) All() map[string][]Rules {
+	return s.value.Load().(map[string][]Rules)
+}
+
+// Swap atomically replaces the active ruleset. It is the only way callers outside this package can mutate
+// the store, so every reader always observes a complete, consistent ruleset
+func (s *RuleStore) Swap(next map[string][]Rules) {
+	s.value.Store(next)
+}
+
+// Store is the global RuleStore used by the packet-processing hot path. It starts out empty and is first
+// populated by LoadRulesDir at startup, then kept up to date by WatchRulesDir
+var Store = NewRuleStore()
+
+// GlobalRules is a deprecated compatibility accessor for code written against the old plain-map global this
+// package used before RuleStore existed. A bare map var can't be kept in sync with Swap without racing
+// against concurrent readers, so this now reads straight through Store's own atomic.Value instead ; callers
+// go from `rules.GlobalRules[proto]` to `rules.GlobalRules()[proto]`. New code should call Store.Get/Store.All
+// directly
+func GlobalRules() map[string][]Rules {
+	return Store.All()
+}
@@ -0,0 +1,131 @@
+package rules
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long WatchRulesDir waits after the last filesystem event before re-parsing the rules
+// directory, so a burst of writes from an editor or a `cp -r` only triggers a single reload
+const watchDebounce = 300 * time.Millisecond
+
+// WatchRulesDir watches rulesDir for create/write/remove/rename events on .yml files and, after debouncing a
+// burst of events, re-parses the whole directory into a candidate ruleset. The candidate is only swapped into
+// Store if it parses successfully ; on error the previous ruleset is kept and the error is logged, so a typo
+// in one rule file never takes Melody down. It blocks until ctx is cancelled
+func WatchRulesDir(ctx context.Context, rulesDir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := addRecursive(watcher, rulesDir); err != nil {
+		return err
+	}
+
+	var debounce *time.Timer
+
+	// Seed previousRaw from the rules directory as it stands right now, so the first reload only reports
+	// rules that actually changed since startup instead of logging the whole ruleset as "added". This mirrors
+	// the parse LoadRulesDir already did at startup ; its result isn't reused here since LoadRulesDir only
+	// returns the rule count, not the raw-by-name map this diff needs
+	previousRaw := make(map[string]RawRule)
+	if _, rawByName, _, err := parseRulesDir(rulesDir); err != nil {
+		log.Println("Rules watcher: failed to seed initial rule snapshot for diffing :", err)
+	} else {
+		previousRaw = rawByName
+	}
+
+	reload := func() {
+		candidate, rawByName, total, err := parseRulesDir(rulesDir)
+		if err != nil {
+			log.Println("Rules reload rejected, keeping the previous ruleset :", err)
+			return
+		}
+
+		Store.Swap(candidate)
+		logRulesetDiff(previousRaw, rawByName)
+		previousRaw = rawByName
+		log.Println("Rules reloaded,", total, "active rules")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !strings.HasSuffix(event.Name, ".yml") {
+				continue
+			}
+
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.AfterFunc(watchDebounce, reload)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("Rules watcher error :", err)
+		}
+	}
+}
+
+// addRecursive adds rulesDir and every subdirectory it contains to the watcher, since fsnotify does not
+// support recursive watches natively
+func addRecursive(watcher *fsnotify.Watcher, rulesDir string) error {
+	return filepath.Walk(rulesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		return watcher.Add(path)
+	})
+}
+
+// logRulesetDiff summarises the rule names that were added, removed or changed between two reloads. It diffs
+// on RawRule -- the plain, pre-compile YAML shape -- rather than the compiled Rule, since Rule carries compiled
+// *regexp.Regexp and pathtemplate.Template values whose internal fields reflect.DeepEqual would walk and can
+// report as "changed" even when the rule's source text is byte-for-byte identical across a reload
+func logRulesetDiff(previous, next map[string]RawRule) {
+	var added, removed, changed []string
+
+	for name, rule := range next {
+		old, existed := previous[name]
+		if !existed {
+			added = append(added, name)
+			continue
+		}
+
+		if !reflect.DeepEqual(old, rule) {
+			changed = append(changed, name)
+		}
+	}
+
+	for name := range previous {
+		if _, stillExists := next[name]; !stillExists {
+			removed = append(removed, name)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+		return
+	}
+
+	log.Printf("Rules reload summary : %d added %v, %d removed %v, %d changed %v",
+		len(added), added, len(removed), removed, len(changed), changed)
+}
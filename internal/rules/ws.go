@@ -0,0 +1,65 @@
+package rules
+
+import (
+	"regexp"
+
+	"github.com/bonjourmalware/melody/internal/events"
+)
+
+// WSConditions describes the optional conditions of a Rule whose Layer is "ws". They complement the common
+// conditions (host, tags, ...) shared by every layer and are only evaluated against WSEvent
+type WSConditions struct {
+	Opcode     []uint8 `yaml:"opcode"`
+	Direction  string  `yaml:"direction"`
+	Masked     *bool   `yaml:"masked"`
+	Payload    string  `yaml:"payload"`
+	payloadRgx *regexp.Regexp
+}
+
+// Compile resolves Payload into a regexp.Regexp once at rule-load time, so Match never pays for compiling it
+// again on every captured frame
+func (c *WSConditions) Compile() error {
+	if c.Payload == "" {
+		return nil
+	}
+
+	rgx, err := regexp.Compile(c.Payload)
+	if err != nil {
+		return err
+	}
+
+	c.payloadRgx = rgx
+
+	return nil
+}
+
+// Match reports whether the given WebSocket frame satisfies the WS-specific conditions
+func (c WSConditions) Match(ev events.WSEvent) bool {
+	if len(c.Opcode) > 0 {
+		var found bool
+		for _, opcode := range c.Opcode {
+			if ev.Opcode == opcode {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if c.Direction != "" && c.Direction != string(ev.Direction) {
+		return false
+	}
+
+	if c.Masked != nil && *c.Masked != ev.Masked {
+		return false
+	}
+
+	if c.payloadRgx != nil && !c.payloadRgx.Match(ev.Payload.Raw) {
+		return false
+	}
+
+	return true
+}
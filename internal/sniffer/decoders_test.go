@@ -0,0 +1,109 @@
+package sniffer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeMsgPack(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "nil", body: []byte{0xc0}, want: nil},
+		{name: "false", body: []byte{0xc2}, want: false},
+		{name: "true", body: []byte{0xc3}, want: true},
+		{name: "positive fixint", body: []byte{0x2a}, want: int64(42)},
+		{name: "negative fixint", body: []byte{0xff}, want: int64(-1)},
+		{name: "fixstr", body: append([]byte{0xa5}, "hello"...), want: "hello"},
+		{name: "fixarray", body: []byte{0x92, 0x01, 0x02}, want: []interface{}{int64(1), int64(2)}},
+		{name: "fixmap", body: append([]byte{0x81, 0xa1, 'k'}, 0x01), want: map[string]interface{}{"k": int64(1)}},
+		{name: "empty input", body: []byte{}, wantErr: true},
+		{name: "truncated fixstr", body: []byte{0xa5, 'h', 'i'}, wantErr: true},
+		{name: "unsupported type code", body: []byte{0xc1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeMsgPack(tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeMsgPack() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("decodeMsgPack() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffProtobuf(t *testing.T) {
+	// field 1 (varint) = 150, field 2 (length-delimited) = "test"
+	body := []byte{0x08, 0x96, 0x01, 0x12, 0x04, 't', 'e', 's', 't'}
+
+	fields := sniffProtobuf(body)
+	if len(fields) != 2 {
+		t.Fatalf("sniffProtobuf() returned %d fields, want 2", len(fields))
+	}
+
+	if fields[0].Field != 1 || fields[0].Wire != wireVarint || fields[0].Value != uint64(150) {
+		t.Errorf("fields[0] = %+v, want field 1 varint 150", fields[0])
+	}
+
+	if fields[1].Field != 2 || fields[1].Wire != wireLengthDelim {
+		t.Errorf("fields[1] = %+v, want field 2 length-delimited", fields[1])
+	}
+
+	if !reflect.DeepEqual(fields[1].Value, []byte("test")) {
+		t.Errorf("fields[1].Value = %#v, want raw bytes %q", fields[1].Value, "test")
+	}
+}
+
+func TestSniffProtobufStopsOnMalformedInput(t *testing.T) {
+	// a length-delimited field declaring a length longer than the remaining bytes
+	body := []byte{0x12, 0x7f, 'x'}
+
+	fields := sniffProtobuf(body)
+	if len(fields) != 0 {
+		t.Fatalf("sniffProtobuf() = %v, want no fields on truncated length-delimited data", fields)
+	}
+}
+
+func TestSniffGRPCSingleUncompressedFrame(t *testing.T) {
+	message := []byte{0x08, 0x01} // field 1 varint = 1
+
+	frame := append([]byte{0x00, 0x00, 0x00, 0x00, byte(len(message))}, message...)
+
+	frames := sniffGRPC(frame)
+	if len(frames) != 1 {
+		t.Fatalf("sniffGRPC() returned %d frames, want 1", len(frames))
+	}
+
+	if frames[0].Compressed {
+		t.Errorf("frames[0].Compressed = true, want false")
+	}
+	if frames[0].Length != uint32(len(message)) {
+		t.Errorf("frames[0].Length = %d, want %d", frames[0].Length, len(message))
+	}
+	if len(frames[0].Message) != 1 {
+		t.Fatalf("frames[0].Message = %v, want one decoded protobuf field", frames[0].Message)
+	}
+}
+
+func TestSniffGRPCTruncatedBodyYieldsPartialFrame(t *testing.T) {
+	// shorter than the 5-byte frame header
+	body := []byte{0x00, 0x00}
+
+	frames := sniffGRPC(body)
+	if len(frames) != 1 {
+		t.Fatalf("sniffGRPC() returned %d frames, want 1 partial frame", len(frames))
+	}
+	if frames[0].Length != uint32(len(body)) {
+		t.Errorf("frames[0].Length = %d, want %d", frames[0].Length, len(body))
+	}
+}
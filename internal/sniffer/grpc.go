@@ -0,0 +1,50 @@
+package sniffer
+
+import "encoding/binary"
+
+// grpcFrameHeaderLen is the size of the length-prefixed framing gRPC wraps every message in : a 1-byte
+// compression flag followed by a 4-byte big-endian message length
+const grpcFrameHeaderLen = 5
+
+// GRPCFrame describes a single length-prefixed message found in an application/grpc* body. Decoding the
+// message itself requires the service's protobuf descriptors, which Melody doesn't have access to, so frames
+// are recorded by size and compression flag and their raw content is sniffed as protobuf on a best-effort basis
+type GRPCFrame struct {
+	Compressed bool         `json:"compressed"`
+	Length     uint32       `json:"length"`
+	Message    []ProtoField `json:"message,omitempty"`
+}
+
+// sniffGRPC splits body into its length-prefixed gRPC frames. A body that doesn't fit the framing (too short,
+// or a declared length that runs past the end of the body) is reported as a single truncated frame so the
+// caller still gets partial visibility instead of nothing
+func sniffGRPC(body []byte) []GRPCFrame {
+	var frames []GRPCFrame
+
+	for len(body) > 0 {
+		if len(body) < grpcFrameHeaderLen {
+			frames = append(frames, GRPCFrame{Length: uint32(len(body))})
+			break
+		}
+
+		compressed := body[0] != 0
+		length := binary.BigEndian.Uint32(body[1:grpcFrameHeaderLen])
+
+		frame := GRPCFrame{Compressed: compressed, Length: length}
+
+		remaining := body[grpcFrameHeaderLen:]
+		end := int(length)
+		if end > len(remaining) {
+			end = len(remaining)
+		}
+
+		if !compressed {
+			frame.Message = sniffProtobuf(remaining[:end])
+		}
+
+		frames = append(frames, frame)
+		body = remaining[end:]
+	}
+
+	return frames
+}
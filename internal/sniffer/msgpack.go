@@ -0,0 +1,215 @@
+package sniffer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// decodeMsgPack decodes a single MessagePack-encoded value from body, covering the format's common types
+// (nil, bool, int, float, str, bin, array, map). It's a best-effort decoder meant for sniffing request
+// bodies, not a general-purpose codec : an unsupported or truncated encoding simply returns an error
+func decodeMsgPack(body []byte) (interface{}, error) {
+	value, _, err := decodeMsgPackValue(body)
+	return value, err
+}
+
+func decodeMsgPackValue(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of msgpack input")
+	}
+
+	code := b[0]
+	rest := b[1:]
+
+	switch {
+	case code <= 0x7f: // positive fixint
+		return int64(code), rest, nil
+	case code >= 0xe0: // negative fixint
+		return int64(int8(code)), rest, nil
+	case code>>5 == 0x05: // fixstr 101xxxxx
+		n := int(code & 0x1f)
+		return readMsgPackString(rest, n)
+	case code>>4 == 0x08: // fixmap 1000xxxx
+		return readMsgPackMap(rest, int(code&0x0f))
+	case code>>4 == 0x09: // fixarray 1001xxxx
+		return readMsgPackArray(rest, int(code&0x0f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, rest, nil
+	case 0xc2:
+		return false, rest, nil
+	case 0xc3:
+		return true, rest, nil
+	case 0xca:
+		v, rest, err := readMsgPackUint32(rest)
+		return math.Float32frombits(v), rest, err
+	case 0xcb:
+		v, rest, err := readMsgPackUint64(rest)
+		return math.Float64frombits(v), rest, err
+	case 0xcc:
+		return readMsgPackUint(rest, 1)
+	case 0xcd:
+		return readMsgPackUint(rest, 2)
+	case 0xce:
+		return readMsgPackUint(rest, 4)
+	case 0xcf:
+		return readMsgPackUint(rest, 8)
+	case 0xd0:
+		return readMsgPackInt(rest, 1)
+	case 0xd1:
+		return readMsgPackInt(rest, 2)
+	case 0xd2:
+		return readMsgPackInt(rest, 4)
+	case 0xd3:
+		return readMsgPackInt(rest, 8)
+	case 0xd9:
+		if len(rest) < 1 {
+			return nil, nil, fmt.Errorf("truncated str8 length")
+		}
+		return readMsgPackString(rest[1:], int(rest[0]))
+	case 0xda:
+		n, rest, err := readMsgPackUint(rest, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgPackString(rest, int(n.(uint64)))
+	case 0xc4:
+		if len(rest) < 1 {
+			return nil, nil, fmt.Errorf("truncated bin8 length")
+		}
+		n := int(rest[0])
+		rest = rest[1:]
+		if len(rest) < n {
+			return nil, nil, fmt.Errorf("truncated bin8 data")
+		}
+		return rest[:n], rest[n:], nil
+	case 0xdc:
+		n, rest, err := readMsgPackUint(rest, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgPackArray(rest, int(n.(uint64)))
+	case 0xde:
+		n, rest, err := readMsgPackUint(rest, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		return readMsgPackMap(rest, int(n.(uint64)))
+	}
+
+	return nil, nil, fmt.Errorf("unsupported msgpack type code 0x%x", code)
+}
+
+func readMsgPackString(b []byte, n int) (interface{}, []byte, error) {
+	if len(b) < n {
+		return nil, nil, fmt.Errorf("truncated msgpack string")
+	}
+
+	return string(b[:n]), b[n:], nil
+}
+
+func readMsgPackArray(b []byte, n int) (interface{}, []byte, error) {
+	arr := make([]interface{}, 0, n)
+
+	for i := 0; i < n; i++ {
+		var (
+			item interface{}
+			err  error
+		)
+
+		item, b, err = decodeMsgPackValue(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		arr = append(arr, item)
+	}
+
+	return arr, b, nil
+}
+
+func readMsgPackMap(b []byte, n int) (interface{}, []byte, error) {
+	m := make(map[string]interface{}, n)
+
+	for i := 0; i < n; i++ {
+		var (
+			key, value interface{}
+			err        error
+		)
+
+		key, b, err = decodeMsgPackValue(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		value, b, err = decodeMsgPackValue(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		m[fmt.Sprintf("%v", key)] = value
+	}
+
+	return m, b, nil
+}
+
+func readMsgPackUint(b []byte, size int) (interface{}, []byte, error) {
+	if len(b) < size {
+		return nil, nil, fmt.Errorf("truncated msgpack uint%d", size*8)
+	}
+
+	var v uint64
+	switch size {
+	case 1:
+		v = uint64(b[0])
+	case 2:
+		v = uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		v = uint64(binary.BigEndian.Uint32(b))
+	case 8:
+		v = binary.BigEndian.Uint64(b)
+	}
+
+	return v, b[size:], nil
+}
+
+func readMsgPackInt(b []byte, size int) (interface{}, []byte, error) {
+	v, rest, err := readMsgPackUint(b, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uv := v.(uint64)
+
+	switch size {
+	case 1:
+		return int64(int8(uv)), rest, nil
+	case 2:
+		return int64(int16(uv)), rest, nil
+	case 4:
+		return int64(int32(uv)), rest, nil
+	default:
+		return int64(uv), rest, nil
+	}
+}
+
+func readMsgPackUint32(b []byte) (uint32, []byte, error) {
+	v, rest, err := readMsgPackUint(b, 4)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return uint32(v.(uint64)), rest, nil
+}
+
+func readMsgPackUint64(b []byte) (uint64, []byte, error) {
+	v, rest, err := readMsgPackUint(b, 8)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return v.(uint64), rest, nil
+}
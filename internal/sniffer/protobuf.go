@@ -0,0 +1,87 @@
+package sniffer
+
+import "encoding/binary"
+
+// Protobuf wire types, as defined by the protocol buffers encoding spec
+const (
+	wireVarint      = 0
+	wire64Bit       = 1
+	wireLengthDelim = 2
+	wire32Bit       = 5
+)
+
+// ProtoField is a single unknown-field entry recovered from a protobuf message without its descriptor : the
+// field number and wire type are always known, the decoded Value is a best-effort guess (a length-delimited
+// field is tried as a nested message, then falls back to raw bytes)
+type ProtoField struct {
+	Field int         `json:"field"`
+	Wire  int         `json:"wire"`
+	Value interface{} `json:"value"`
+}
+
+// sniffProtobuf does a best-effort, descriptor-less decode of body as a protobuf message, recovering field
+// numbers, wire types and values the same way protoc's --decode_raw does. It stops as soon as the bytes stop
+// looking like valid protobuf and returns the fields decoded up to that point (nil if none), rather than
+// erroring, since callers only use this as a sniffing hint
+func sniffProtobuf(body []byte) []ProtoField {
+	var fields []ProtoField
+
+	for len(body) > 0 {
+		tag, n := binary.Uvarint(body)
+		if n <= 0 {
+			return fields
+		}
+		body = body[n:]
+
+		fieldNum := int(tag >> 3)
+		wireType := int(tag & 0x7)
+
+		if fieldNum == 0 {
+			return fields
+		}
+
+		switch wireType {
+		case wireVarint:
+			val, n := binary.Uvarint(body)
+			if n <= 0 {
+				return fields
+			}
+			body = body[n:]
+			fields = append(fields, ProtoField{Field: fieldNum, Wire: wireType, Value: val})
+
+		case wire64Bit:
+			if len(body) < 8 {
+				return fields
+			}
+			fields = append(fields, ProtoField{Field: fieldNum, Wire: wireType, Value: binary.LittleEndian.Uint64(body[:8])})
+			body = body[8:]
+
+		case wire32Bit:
+			if len(body) < 4 {
+				return fields
+			}
+			fields = append(fields, ProtoField{Field: fieldNum, Wire: wireType, Value: binary.LittleEndian.Uint32(body[:4])})
+			body = body[4:]
+
+		case wireLengthDelim:
+			length, n := binary.Uvarint(body)
+			if n <= 0 || uint64(len(body)-n) < length {
+				return fields
+			}
+			body = body[n:]
+			data := body[:length]
+			body = body[length:]
+
+			if nested := sniffProtobuf(data); len(nested) > 0 {
+				fields = append(fields, ProtoField{Field: fieldNum, Wire: wireType, Value: nested})
+			} else {
+				fields = append(fields, ProtoField{Field: fieldNum, Wire: wireType, Value: data})
+			}
+
+		default:
+			return fields
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,214 @@
+// Package sniffer classifies an HTTP body by its Content-Type header and raw bytes, and produces a best-effort
+// decoded representation of it so rules can match on structured fields (body.kind, body.parsed) instead of
+// only on raw-byte regexes
+package sniffer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+
+	"github.com/ma111e/melody/internal/config"
+)
+
+// decompressedSizeCeiling bounds how many bytes are read out of a gzip/zlib stream (and out of each
+// multipart part) when config.Cfg.MaxPOSTDataSize isn't set, so a compression bomb can't be inflated
+// unbounded into memory even before that config knob is in scope
+const decompressedSizeCeiling = 10 * 1024 * 1024 // 10 MiB
+
+// bodyReadLimit returns the byte ceiling applied to decompressed/decoded body content, mirroring the cap
+// already applied to the raw captured body via config.Cfg.MaxPOSTDataSize
+func bodyReadLimit() int64 {
+	if config.Cfg.MaxPOSTDataSize > 0 {
+		return int64(config.Cfg.MaxPOSTDataSize)
+	}
+
+	return decompressedSizeCeiling
+}
+
+// limitedReadAll reads at most bodyReadLimit() bytes from r, so a decompression bomb or a pathological
+// multipart part is truncated instead of being fully buffered in memory
+func limitedReadAll(r io.Reader) ([]byte, error) {
+	return ioutil.ReadAll(io.LimitReader(r, bodyReadLimit()))
+}
+
+// Content kinds recognised by Sniff
+const (
+	KindJSON      = "json"
+	KindXML       = "xml"
+	KindForm      = "form"
+	KindMultipart = "multipart"
+	KindProtobuf  = "protobuf"
+	KindGRPC      = "grpc"
+	KindGzip      = "gzip"
+	KindDeflate   = "deflate"
+	KindMsgPack   = "msgpack"
+	KindUnknown   = "unknown"
+)
+
+// Result is the outcome of sniffing a body : its classified Kind and, when decoding was possible, a Parsed
+// representation of it
+type Result struct {
+	Kind   string      `json:"kind"`
+	Parsed interface{} `json:"parsed,omitempty"`
+}
+
+// Sniff classifies body using contentType as a hint and the byte content itself, and decodes it when the
+// format is understood. It never returns an error : a body that can't be decoded is returned with its best
+// guessed Kind and a nil Parsed
+func Sniff(contentType string, body []byte) Result {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+
+	if len(body) == 0 {
+		return Result{Kind: KindUnknown}
+	}
+
+	if isGzip(body) {
+		return sniffCompressed(KindGzip, decompressGzip, mediaType, body)
+	}
+
+	if isZlib(body) {
+		return sniffCompressed(KindDeflate, decompressZlib, mediaType, body)
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "application/grpc"):
+		return Result{Kind: KindGRPC, Parsed: sniffGRPC(body)}
+	case strings.Contains(mediaType, "protobuf"):
+		return Result{Kind: KindProtobuf, Parsed: sniffProtobuf(body)}
+	case strings.Contains(mediaType, "msgpack"):
+		parsed, _ := decodeMsgPack(body)
+		return Result{Kind: KindMsgPack, Parsed: parsed}
+	case mediaType == "application/x-www-form-urlencoded":
+		return Result{Kind: KindForm, Parsed: sniffForm(body)}
+	case strings.HasPrefix(mediaType, "multipart/"):
+		return Result{Kind: KindMultipart, Parsed: sniffMultipart(params["boundary"], body)}
+	case mediaType == "application/json", mediaType == "text/json", looksLikeJSON(body):
+		var parsed interface{}
+		if err := json.Unmarshal(body, &parsed); err == nil {
+			return Result{Kind: KindJSON, Parsed: parsed}
+		}
+	case strings.Contains(mediaType, "xml"), looksLikeXML(body):
+		var parsed xmlNode
+		if err := xml.Unmarshal(body, &parsed); err == nil {
+			return Result{Kind: KindXML, Parsed: parsed}
+		}
+	}
+
+	return Result{Kind: KindUnknown}
+}
+
+func sniffCompressed(kind string, decompress func([]byte) ([]byte, error), innerMediaType string, body []byte) Result {
+	decoded, err := decompress(body)
+	if err != nil {
+		return Result{Kind: kind}
+	}
+
+	inner := Sniff(innerMediaType, decoded)
+
+	return Result{Kind: kind, Parsed: inner}
+}
+
+func isGzip(body []byte) bool {
+	return len(body) >= 2 && body[0] == 0x1f && body[1] == 0x8b
+}
+
+func isZlib(body []byte) bool {
+	return len(body) >= 2 && body[0] == 0x78 &&
+		(body[1] == 0x01 || body[1] == 0x9c || body[1] == 0xda || body[1] == 0x5e)
+}
+
+func decompressGzip(body []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return limitedReadAll(r)
+}
+
+func decompressZlib(body []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return limitedReadAll(r)
+}
+
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return false
+	}
+
+	return trimmed[0] == '{' || trimmed[0] == '['
+}
+
+func looksLikeXML(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+
+	return len(trimmed) > 0 && trimmed[0] == '<'
+}
+
+func sniffForm(body []byte) map[string][]string {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil
+	}
+
+	return values
+}
+
+// MultipartPart summarises a single part of a multipart/form-data body without fully decoding its content
+type MultipartPart struct {
+	FieldName   string `json:"field_name"`
+	FileName    string `json:"file_name,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int    `json:"size"`
+}
+
+func sniffMultipart(boundary string, body []byte) []MultipartPart {
+	if boundary == "" {
+		return nil
+	}
+
+	var parts []MultipartPart
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+
+		data, _ := limitedReadAll(part)
+		parts = append(parts, MultipartPart{
+			FieldName:   part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        len(data),
+		})
+	}
+
+	return parts
+}
+
+// xmlNode is a generic XML tree, used to decode an arbitrary XML body without a pre-known schema
+type xmlNode struct {
+	XMLName xml.Name   `json:"tag"`
+	Attrs   []xml.Attr `xml:",any,attr" json:"attrs,omitempty"`
+	Content string     `xml:",chardata" json:"content,omitempty"`
+	Nodes   []xmlNode  `xml:",any" json:"children,omitempty"`
+}